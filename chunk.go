@@ -0,0 +1,232 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var numericColumnTypes = map[string]bool{
+	"tinyint": true, "smallint": true, "mediumint": true,
+	"int": true, "bigint": true, "year": true,
+}
+
+// discoverNumericPK returns the table's primary key column if it's a single
+// numeric column; ok is false for composite or non-numeric keys.
+func discoverNumericPK(db queryable, dbName, table string) (pkCol string, ok bool) {
+	rows, err := db.Query(fmt.Sprintf("SHOW KEYS FROM %s WHERE Key_name = 'PRIMARY'", table))
+	if err != nil {
+		return "", false
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", false
+	}
+	nameIdx := -1
+	for i, c := range cols {
+		if c == "Column_name" {
+			nameIdx = i
+		}
+	}
+	if nameIdx < 0 {
+		return "", false
+	}
+
+	var pkCols []string
+	for rows.Next() {
+		dest := make([]interface{}, len(cols))
+		raw := make([]sql.RawBytes, len(cols))
+		for i := range dest {
+			dest[i] = &raw[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return "", false
+		}
+		pkCols = append(pkCols, string(raw[nameIdx]))
+	}
+	if len(pkCols) != 1 {
+		return "", false
+	}
+
+	dtRows, err := db.Query(
+		"SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+		dbName, table, pkCols[0])
+	if err != nil {
+		return "", false
+	}
+	defer dtRows.Close()
+	if !dtRows.Next() {
+		return "", false
+	}
+	var dataType string
+	if err := dtRows.Scan(&dataType); err != nil {
+		return "", false
+	}
+	if !numericColumnTypes[strings.ToLower(dataType)] {
+		return "", false
+	}
+	return pkCols[0], true
+}
+
+// queryPKRange returns the table's min/max primary key values. ok is false
+// for an empty table (both come back NULL).
+func queryPKRange(db queryable, table, pkCol string) (min, max int64, ok bool, err error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", pkCol, pkCol, table))
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer rows.Close()
+	var minN, maxN sql.NullInt64
+	if rows.Next() {
+		if err := rows.Scan(&minN, &maxN); err != nil {
+			return 0, 0, false, err
+		}
+	}
+	return minN.Int64, maxN.Int64, minN.Valid, nil
+}
+
+// chunkState is the sidecar ".state" file that lets --resume skip chunks
+// already written by a previous run.
+type chunkState struct {
+	LastPK     int64
+	ChunkIndex int
+}
+
+func readChunkState(path string) (chunkState, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return chunkState{}, err
+	}
+	var s chunkState
+	_, err = fmt.Sscanf(string(b), "%d %d", &s.LastPK, &s.ChunkIndex)
+	return s, err
+}
+
+func writeChunkState(path string, s chunkState) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", s.LastPK, s.ChunkIndex)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// dumpTableChunked walks a table's single-column numeric primary key in
+// ranges of chunkSize rows. With splitFiles it writes one file per chunk
+// (table.0000.csv, table.0001.csv, ...) and records progress in a
+// table.state sidecar file for --resume.
+func dumpTableChunked(table string, db queryable, dbName, outputDir, format string, compressOut, skipHeader bool, encryptKey []byte, chunkSize int64, splitFiles, resume bool, progress func(rows int64)) error {
+	pkCol, ok := discoverNumericPK(db, dbName, table)
+	if !ok {
+		fmt.Printf("%s: no single-column numeric primary key, falling back to a full table scan\n", table)
+		return dumpTable(table, db, outputDir, format, compressOut, skipHeader, encryptKey, progress)
+	}
+
+	minPK, maxPK, _, err := queryPKRange(db, table, pkCol)
+	if err != nil {
+		return err
+	}
+
+	statePath := outputDir + "/" + table + ".state"
+	// Start below the table's actual smallest pk (not a hardcoded 0/-1) so
+	// chunk 0 still runs -- and produces an output file -- for an empty
+	// table, and so a negative-valued pk isn't excluded by "pk > lo".
+	lo := minPK - 1
+	var chunkIndex int
+	if resume {
+		if !splitFiles {
+			fmt.Printf("%s: --resume only works together with --split-files, starting from scratch\n", table)
+		} else if s, err := readChunkState(statePath); err == nil {
+			lo = s.LastPK
+			chunkIndex = s.ChunkIndex
+		}
+	}
+
+	var sink *dumpSink
+	var totalRows int64
+	for lo < maxPK {
+		hi := lo + chunkSize
+		if hi > maxPK {
+			hi = maxPK
+		}
+
+		query := fmt.Sprintf("SELECT * FROM %s WHERE %s > ? AND %s <= ? ORDER BY %s", table, pkCol, pkCol, pkCol)
+		rows, err := db.Query(query, lo, hi)
+		if err != nil {
+			return err
+		}
+
+		columns, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		columnTypes, err := rows.ColumnTypes()
+		if err != nil {
+			return err
+		}
+
+		active := sink
+		if splitFiles {
+			fname := outputName(outputDir, fmt.Sprintf("%s.%04d", table, chunkIndex), format, compressOut, encryptKey)
+			active, err = newDumpSink(fname, format, compressOut, skipHeader, encryptKey, associatedData(table, columns))
+			if err != nil {
+				return err
+			}
+			if err := active.WriteHeader(columns); err != nil {
+				return err
+			}
+		} else if sink == nil {
+			fname := outputName(outputDir, table, format, compressOut, encryptKey)
+			sink, err = newDumpSink(fname, format, compressOut, skipHeader, encryptKey, associatedData(table, columns))
+			if err != nil {
+				return err
+			}
+			if err := sink.WriteHeader(columns); err != nil {
+				return err
+			}
+			active = sink
+		}
+
+		for rows.Next() {
+			values := make([]interface{}, len(columns))
+			scanArgs := make([]interface{}, len(values))
+			for i := range values {
+				scanArgs[i] = &values[i]
+			}
+			if err := rows.Scan(scanArgs...); err != nil {
+				return err
+			}
+			if err := active.WriteRow(columnTypes, values); err != nil {
+				return err
+			}
+			totalRows++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(totalRows)
+		}
+
+		if splitFiles {
+			if err := active.Close(); err != nil {
+				return err
+			}
+		}
+
+		lo = hi
+		chunkIndex++
+		if splitFiles {
+			if err := writeChunkState(statePath, chunkState{LastPK: lo, ChunkIndex: chunkIndex}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if sink != nil {
+		return sink.Close()
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+// TestDumpTableChunkedNegativePK covers a table whose primary key values are
+// all negative, which the lo := int64(-1) sentinel used to drop entirely.
+func TestDumpTableChunkedNegativePK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SHOW KEYS FROM negtable WHERE Key_name = 'PRIMARY'")).
+		WillReturnRows(sqlmock.NewRows([]string{"Column_name"}).AddRow("id"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COLUMN_NAME = ?")).
+		WithArgs("testdb", "negtable", "id").
+		WillReturnRows(sqlmock.NewRows([]string{"DATA_TYPE"}).AddRow("bigint"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MIN(id), MAX(id) FROM negtable")).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max"}).AddRow(-500, -100))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM negtable WHERE id > ? AND id <= ? ORDER BY id")).
+		WithArgs(int64(-501), int64(-100)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "val"}).
+			AddRow(-500, "a").
+			AddRow(-100, "b"))
+
+	outputDir := t.TempDir()
+	if err := dumpTableChunked("negtable", db, "testdb", outputDir, "csv", false, false, nil, 1000, false, false, nil); err != nil {
+		t.Fatalf("dumpTableChunked: %s", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(outputDir, "negtable.csv"))
+	if err != nil {
+		t.Fatalf("reading output: %s", err)
+	}
+	got := string(b)
+	for _, want := range []string{"-500", "a", "-100", "b"} {
+		if !regexp.MustCompile(want).MatchString(got) {
+			t.Errorf("output %q missing %q", got, want)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %s", err)
+	}
+}
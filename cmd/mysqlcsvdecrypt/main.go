@@ -0,0 +1,139 @@
+// Command mysqlcsvdecrypt reverses the ChaCha20-Poly1305 container that
+// mysqlcsvdump writes when run with --encrypt-key/--encrypt-keyfile.
+package main
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var encMagic = [4]byte{'M', 'C', 'E', '1'}
+
+const encVersion1 = 1
+
+func parseKey(raw string) ([]byte, error) {
+	if key, err := hex.DecodeString(raw); err == nil && len(key) == chacha20poly1305.KeySize {
+		return key, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("key must be %d-byte hex or base64", chacha20poly1305.KeySize)
+	}
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("key must decode to %d bytes, got %d", chacha20poly1305.KeySize, len(key))
+	}
+	return key, nil
+}
+
+func decrypt(in io.Reader, out io.Writer, aead cipher.AEAD) error {
+	var header [5]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+	if [4]byte{header[0], header[1], header[2], header[3]} != encMagic {
+		return fmt.Errorf("not a mysqlcsvdump encrypted file (bad magic)")
+	}
+	if header[4] != encVersion1 {
+		return fmt.Errorf("unsupported container version %d", header[4])
+	}
+
+	var adLen [4]byte
+	if _, err := io.ReadFull(in, adLen[:]); err != nil {
+		return fmt.Errorf("reading associated data length: %w", err)
+	}
+	ad := make([]byte, binary.BigEndian.Uint32(adLen[:]))
+	if _, err := io.ReadFull(in, ad); err != nil {
+		return fmt.Errorf("reading associated data: %w", err)
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	var frameLen [4]byte
+	for {
+		if _, err := io.ReadFull(in, nonce); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("reading nonce: %w", err)
+		}
+		if _, err := io.ReadFull(in, frameLen[:]); err != nil {
+			return fmt.Errorf("reading frame length: %w", err)
+		}
+		sealed := make([]byte, binary.BigEndian.Uint32(frameLen[:]))
+		if _, err := io.ReadFull(in, sealed); err != nil {
+			return fmt.Errorf("reading chunk: %w", err)
+		}
+		plain, err := aead.Open(nil, nonce, sealed, ad)
+		if err != nil {
+			return fmt.Errorf("decrypting chunk: %w", err)
+		}
+		if _, err := out.Write(plain); err != nil {
+			return err
+		}
+	}
+}
+
+func main() {
+	keyFlag := flag.String("key", "", "hex or base64 encoded 32-byte key")
+	keyfileFlag := flag.String("keyfile", "", "path to a file containing the key")
+	flag.Parse()
+	args := flag.Args()
+
+	if (*keyFlag == "") == (*keyfileFlag == "") {
+		fmt.Fprintln(os.Stderr, "specify exactly one of -key or -keyfile")
+		os.Exit(1)
+	}
+	raw := *keyFlag
+	if *keyfileFlag != "" {
+		b, err := os.ReadFile(*keyfileFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading keyfile: %s\n", err)
+			os.Exit(1)
+		}
+		raw = strings.TrimSpace(string(b))
+	}
+	key, err := parseKey(raw)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bad key: %s\n", err)
+		os.Exit(1)
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	var in io.Reader = os.Stdin
+	var out io.Writer = os.Stdout
+	if len(args) > 0 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+	if len(args) > 1 {
+		f, err := os.Create(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := decrypt(in, out, aead); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
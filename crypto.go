@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// encMagic identifies a mysqlcsvdump encrypted container. encVersion1 is the
+// only framing version understood so far.
+var encMagic = [4]byte{'M', 'C', 'E', '1'}
+
+const encVersion1 = 1
+
+// encChunkSize is the amount of plaintext sealed per chunk so a decrypter
+// never has to hold more than one chunk in memory.
+const encChunkSize = 64 * 1024
+
+// associatedData binds a table's name and column list into every sealed chunk.
+func associatedData(table string, columns []string) []byte {
+	return []byte(table + ":" + strings.Join(columns, ","))
+}
+
+// resolveEncryptKey resolves the --encrypt-key/--encrypt-keyfile flags into a
+// raw key, or returns nil if encryption wasn't requested.
+func resolveEncryptKey(keyFlag, keyfileFlag string) ([]byte, error) {
+	if keyFlag == "" && keyfileFlag == "" {
+		return nil, nil
+	}
+	if keyFlag != "" && keyfileFlag != "" {
+		return nil, fmt.Errorf("specify either --encrypt-key or --encrypt-keyfile, not both")
+	}
+	raw := keyFlag
+	if keyfileFlag != "" {
+		b, err := os.ReadFile(keyfileFlag)
+		if err != nil {
+			return nil, err
+		}
+		raw = strings.TrimSpace(string(b))
+	}
+	return parseEncryptKey(raw)
+}
+
+func parseEncryptKey(raw string) ([]byte, error) {
+	if key, err := hex.DecodeString(raw); err == nil && len(key) == chacha20poly1305.KeySize {
+		return key, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("key must be %d-byte hex or base64", chacha20poly1305.KeySize)
+	}
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("key must decode to %d bytes, got %d", chacha20poly1305.KeySize, len(key))
+	}
+	return key, nil
+}
+
+// encryptWriter wraps an io.Writer, sealing everything written to it as a
+// sequence of length-prefixed ChaCha20-Poly1305 chunks.
+type encryptWriter struct {
+	w           io.Writer
+	aead        cipher.AEAD
+	ad          []byte
+	buf         []byte
+	wroteHeader bool
+}
+
+func newEncryptWriter(w io.Writer, key, ad []byte) (*encryptWriter, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, ad: ad, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+func (e *encryptWriter) writeHeader() error {
+	if e.wroteHeader {
+		return nil
+	}
+	e.wroteHeader = true
+	if _, err := e.w.Write(encMagic[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write([]byte{encVersion1}); err != nil {
+		return err
+	}
+	var adLen [4]byte
+	binary.BigEndian.PutUint32(adLen[:], uint32(len(e.ad)))
+	if _, err := e.w.Write(adLen[:]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.ad)
+	return err
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if err := e.writeHeader(); err != nil {
+		return 0, err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		room := encChunkSize - len(e.buf)
+		take := room
+		if take > len(p) {
+			take = len(p)
+		}
+		e.buf = append(e.buf, p[:take]...)
+		p = p[take:]
+		if len(e.buf) == encChunkSize {
+			if err := e.flushChunk(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) flushChunk() error {
+	if len(e.buf) == 0 {
+		return nil
+	}
+	var nonce [chacha20poly1305.NonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	sealed := e.aead.Seal(nil, nonce[:], e.buf, e.ad)
+	e.buf = e.buf[:0]
+
+	var frameLen [4]byte
+	binary.BigEndian.PutUint32(frameLen[:], uint32(len(sealed)))
+	if _, err := e.w.Write(nonce[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(frameLen[:]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// Close flushes any buffered plaintext as a final (possibly short) chunk. It
+// does not close the underlying writer.
+func (e *encryptWriter) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.flushChunk()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	csv "github.com/JensRantil/go-csv"
+)
+
+// rowWriter is implemented by each supported --format so dumpTable doesn't
+// need to know how a row is actually serialized.
+type rowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(columnTypes []*sql.ColumnType, values []interface{}) error
+	Close() error
+}
+
+func newRowWriter(format string, out io.Writer, skipHeader bool) (rowWriter, error) {
+	switch format {
+	case "", "csv":
+		delimiter, _, _ := strings.NewReader(*csvSep).ReadRune()
+		quoteChar, _, _ := strings.NewReader(*csvOptEncloser).ReadRune()
+		escapeChar, _, _ := strings.NewReader(*csvEscape).ReadRune()
+		dialect := csv.Dialect{
+			Delimiter:   delimiter,
+			QuoteChar:   quoteChar,
+			EscapeChar:  escapeChar,
+			DoubleQuote: csv.NoDoubleQuote,
+		}
+		return &csvRowWriter{w: csv.NewDialectWriter(out, dialect), skipHeader: skipHeader}, nil
+	case "ndjson":
+		return &ndjsonRowWriter{out: out}, nil
+	case "json-array":
+		return &jsonArrayRowWriter{out: out}, nil
+	default:
+		return nil, fmt.Errorf("unknown --format %q (want csv, ndjson or json-array)", format)
+	}
+}
+
+// csvRowWriter is the original tab/comma-separated writer, unchanged: values
+// are still stringified with fmt.Sprintf, so NULLs and numeric types keep
+// their historical (lossy) rendering.
+type csvRowWriter struct {
+	w          csv.Writer
+	skipHeader bool
+}
+
+func (c *csvRowWriter) WriteHeader(columns []string) error {
+	if c.skipHeader {
+		return nil
+	}
+	return c.w.Write(columns)
+}
+
+func (c *csvRowWriter) WriteRow(columnTypes []*sql.ColumnType, values []interface{}) error {
+	csvData := make([]string, 0, len(values))
+	for _, value := range values {
+		switch value.(type) {
+		default:
+			s := fmt.Sprintf("%s", value)
+			csvData = append(csvData, string(s))
+		}
+	}
+	return c.w.Write(csvData)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonRowWriter writes one JSON object per line, keyed by column name.
+type ndjsonRowWriter struct {
+	out     io.Writer
+	columns []string
+}
+
+func (n *ndjsonRowWriter) WriteHeader(columns []string) error {
+	n.columns = columns
+	return nil
+}
+
+func (n *ndjsonRowWriter) WriteRow(columnTypes []*sql.ColumnType, values []interface{}) error {
+	if err := writeJSONObject(n.out, n.columns, columnTypes, values); err != nil {
+		return err
+	}
+	_, err := n.out.Write([]byte("\n"))
+	return err
+}
+
+func (n *ndjsonRowWriter) Close() error {
+	return nil
+}
+
+// jsonArrayRowWriter writes the whole table as a single JSON array of
+// objects, e.g. for tools that don't want to deal with NDJSON framing.
+type jsonArrayRowWriter struct {
+	out     io.Writer
+	columns []string
+	wrote   bool
+}
+
+func (j *jsonArrayRowWriter) WriteHeader(columns []string) error {
+	j.columns = columns
+	_, err := j.out.Write([]byte("["))
+	return err
+}
+
+func (j *jsonArrayRowWriter) WriteRow(columnTypes []*sql.ColumnType, values []interface{}) error {
+	if j.wrote {
+		if _, err := j.out.Write([]byte(",")); err != nil {
+			return err
+		}
+	}
+	j.wrote = true
+	return writeJSONObject(j.out, j.columns, columnTypes, values)
+}
+
+func (j *jsonArrayRowWriter) Close() error {
+	_, err := j.out.Write([]byte("]"))
+	return err
+}
+
+// writeJSONObject marshals one row as a JSON object, keeping column order
+// (encoding/json would otherwise re-sort a map's keys alphabetically).
+func writeJSONObject(out io.Writer, columns []string, columnTypes []*sql.ColumnType, values []interface{}) error {
+	if _, err := out.Write([]byte("{")); err != nil {
+		return err
+	}
+	for i, col := range columns {
+		if i > 0 {
+			if _, err := out.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		key, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		var ct *sql.ColumnType
+		if i < len(columnTypes) {
+			ct = columnTypes[i]
+		}
+		val, err := json.Marshal(jsonValue(ct, values[i]))
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(key); err != nil {
+			return err
+		}
+		if _, err := out.Write([]byte(":")); err != nil {
+			return err
+		}
+		if _, err := out.Write(val); err != nil {
+			return err
+		}
+	}
+	_, err := out.Write([]byte("}"))
+	return err
+}
+
+// jsonValue converts a scanned column value into something encoding/json
+// renders sensibly, using the driver's column type metadata instead of
+// blindly stringifying everything.
+func jsonValue(ct *sql.ColumnType, raw interface{}) interface{} {
+	if raw == nil {
+		return nil
+	}
+	b, ok := raw.([]byte)
+	if !ok {
+		return raw
+	}
+	if ct == nil {
+		return string(b)
+	}
+	switch strings.ToUpper(ct.DatabaseTypeName()) {
+	case "TINYINT":
+		if n, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			if length, ok := ct.Length(); ok && length == 1 {
+				return n != 0
+			}
+			return n
+		}
+		return string(b)
+	case "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR":
+		if n, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			return n
+		}
+		return string(b)
+	case "DECIMAL", "FLOAT", "DOUBLE", "NUMERIC":
+		if f, err := strconv.ParseFloat(string(b), 64); err == nil {
+			return f
+		}
+		return string(b)
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY":
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return string(b)
+	}
+}
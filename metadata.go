@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"strconv"
+)
+
+// binlogCoords are the replication coordinates a downstream CDC reader needs
+// to know where to start.
+type binlogCoords struct {
+	File         string `json:"file,omitempty"`
+	Position     int64  `json:"position,omitempty"`
+	GTIDExecuted string `json:"gtid_executed,omitempty"`
+}
+
+type columnMeta struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+type tableMeta struct {
+	Name             string       `json:"name"`
+	RowCountEstimate int64        `json:"row_count_estimate"`
+	Columns          []columnMeta `json:"columns"`
+}
+
+type dumpMetadata struct {
+	Binlog binlogCoords `json:"binlog"`
+	Tables []tableMeta  `json:"tables"`
+}
+
+// captureBinlogCoords reads SHOW MASTER STATUS and @@GLOBAL.gtid_executed
+// over the given connection.
+func captureBinlogCoords(ctx context.Context, conn *sql.Conn) (binlogCoords, error) {
+	var coords binlogCoords
+
+	rows, err := conn.QueryContext(ctx, "SHOW MASTER STATUS")
+	if err != nil {
+		return coords, err
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return coords, err
+	}
+	if rows.Next() {
+		raw := make([]sql.RawBytes, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range dest {
+			dest[i] = &raw[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return coords, err
+		}
+		for i, c := range cols {
+			switch c {
+			case "File":
+				coords.File = string(raw[i])
+			case "Position":
+				coords.Position, _ = strconv.ParseInt(string(raw[i]), 10, 64)
+			}
+		}
+	}
+	rows.Close()
+
+	var gtid sql.NullString
+	if err := conn.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&gtid); err != nil {
+		return coords, err
+	}
+	coords.GTIDExecuted = gtid.String
+	return coords, nil
+}
+
+// columnMetaFor reads a table's column names/types/nullability from
+// information_schema.
+func columnMetaFor(db queryable, dbName, table string) ([]columnMeta, error) {
+	rows, err := db.Query(
+		"SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE FROM information_schema.COLUMNS "+
+			"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+		dbName, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []columnMeta
+	for rows.Next() {
+		var name, dataType, isNullable string
+		if err := rows.Scan(&name, &dataType, &isNullable); err != nil {
+			return nil, err
+		}
+		columns = append(columns, columnMeta{Name: name, Type: dataType, Nullable: isNullable == "YES"})
+	}
+	return columns, nil
+}
+
+// writeDumpMetadata writes metadata.json into outputDir.
+func writeDumpMetadata(outputDir string, coords binlogCoords, tables []string, estimates map[string]int64, columns map[string][]columnMeta) error {
+	meta := dumpMetadata{Binlog: coords}
+	for _, table := range tables {
+		meta.Tables = append(meta.Tables, tableMeta{
+			Name:             table,
+			RowCountEstimate: estimates[table],
+			Columns:          columns[table],
+		})
+	}
+
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputDir+"/metadata.json", b, 0644)
+}
@@ -1,15 +1,11 @@
 package main
 
 import (
-	"compress/gzip"
 	"database/sql"
 	"flag"
 	"fmt"
-	csv "github.com/JensRantil/go-csv"
 	_ "github.com/go-sql-driver/mysql"
-	"io"
 	"os"
-	"strings"
 	"unicode/utf8"
 )
 
@@ -18,70 +14,43 @@ type queryable interface {
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 }
 
-func dump(tables []string, db queryable, outputDir string, compressOut bool, skipHeader bool) error {
-	for _, table := range tables {
-		err := dumpTable(table, db, outputDir, compressOut, skipHeader)
-		if err != nil {
-			fmt.Printf("Error dumping %s: %s\n", table, err)
-		}
-	}
-	return nil
-}
-
 var csvSep = flag.String("fields-terminated-by", "\t", "character to terminate fields by")
 var csvOptEncloser = flag.String("fields-optionally-enclosed-by", "\"", "character to enclose fields with when needed")
 var csvEscape = flag.String("fields-escaped-by", "\\", "character to escape special characters with")
 
-func dumpTable(table string, db queryable, outputDir string, compressOut, skipHeader bool) error {
-	fname := outputDir + "/" + table + ".csv"
-	if compressOut {
-		fname = fname + ".gz"
-	}
+var formatExt = map[string]string{
+	"":           ".csv",
+	"csv":        ".csv",
+	"ndjson":     ".ndjson",
+	"json-array": ".json",
+}
 
-	f, err := os.Create(fname)
+func dumpTable(table string, db queryable, outputDir string, format string, compressOut, skipHeader bool, encryptKey []byte, progress func(rows int64)) error {
+	rows, err := db.Query("SELECT * FROM " + table) // Couldn't get placeholder expansion to work here
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	var out io.Writer
-	if compressOut {
-		gzout := gzip.NewWriter(f)
-		defer gzout.Close()
-		out = gzout
-	} else {
-		out = f
-	}
 
-	quoteChar, _, _ := strings.NewReader(*csvOptEncloser).ReadRune()
-	escapeChar, _, _ := strings.NewReader(*csvEscape).ReadRune()
-	dialect := csv.Dialect{
-		Delimiter:   *csvSep,
-		QuoteChar:   quoteChar,
-		EscapeChar:  escapeChar,
-		DoubleQuote: csv.NoDoubleQuote,
+	columns, err := rows.Columns()
+	if err != nil {
+		panic(err.Error())
 	}
-	w := csv.NewDialectWriter(out, dialect)
-
-	rows, err := db.Query("SELECT * FROM " + table) // Couldn't get placeholder expansion to work here
+	columnTypes, err := rows.ColumnTypes()
 	if err != nil {
 		return err
 	}
 
-	columns, err := rows.Columns()
+	sink, err := newDumpSink(outputName(outputDir, table, format, compressOut, encryptKey), format, compressOut, skipHeader, encryptKey, associatedData(table, columns))
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	if !skipHeader {
-		err = w.Write(columns) // Header
-		if err != nil {
-			return err
-		}
+
+	if err := sink.WriteHeader(columns); err != nil {
+		return err
 	}
 
+	var rowsDone int64
 	for rows.Next() {
-		// Shamelessly ripped (and modified) from http://play.golang.org/p/jxza3pbqq9
-
 		// Create interface set
 		values := make([]interface{}, len(columns))
 		scanArgs := make([]interface{}, len(values))
@@ -95,28 +64,20 @@ func dumpTable(table string, db queryable, outputDir string, compressOut, skipHe
 			return err
 		}
 
-		// Print data
-		csvData := make([]string, 0, len(values))
-		for _, value := range values {
-			switch value.(type) {
-			default:
-				s := fmt.Sprintf("%s", value)
-				csvData = append(csvData, string(s))
-			}
-		}
-		err = w.Write(csvData)
-		if err != nil {
+		if err := sink.WriteRow(columnTypes, values); err != nil {
 			return err
 		}
-	}
 
-	w.Flush()
-	err = w.Error()
-	if err != nil {
-		return err
+		rowsDone++
+		if progress != nil && rowsDone%1000 == 0 {
+			progress(rowsDone)
+		}
+	}
+	if progress != nil {
+		progress(rowsDone)
 	}
 
-	return nil
+	return sink.Close()
 }
 
 func getTables(db queryable) ([]string, error) {
@@ -139,10 +100,17 @@ func main() {
 	dbHost := flag.String("hostname", "", "database host")
 	dbPort := flag.Int("port", 3306, "database port")
 	outputDir := flag.String("outdir", "", "where output will be stored")
-	//compressCon := flag.Bool("compress-con", false, "whether compress connection or not")
+	compressProtocol := flag.Bool("compress-protocol", false, "whether to negotiate MySQL protocol compression (CLIENT_COMPRESS) with the server")
 	compressFiles := flag.Bool("compress-file", false, "whether compress connection or not")
 	useTransaction := flag.Bool("single-transaction", true, "whether to wrap everything in a transaction or not.")
 	skipHeader := flag.Bool("skip-header", false, "whether column header should be included or not")
+	format := flag.String("format", "csv", "output format: csv, ndjson or json-array")
+	parallel := flag.Int("parallel", 1, "number of tables to dump concurrently, each on its own connection")
+	chunkSize := flag.Int64("chunk-size", 0, "dump tables with a single-column numeric primary key in ranges of this many rows instead of one SELECT *")
+	splitFiles := flag.Bool("split-files", false, "with -chunk-size, write one file per chunk (table.0000.csv, table.0001.csv, ...) instead of one concatenated file")
+	resume := flag.Bool("resume", false, "with -chunk-size -split-files, skip chunks already completed by a previous interrupted run")
+	encryptKeyFlag := flag.String("encrypt-key", "", "hex or base64 encoded 32-byte key; when set, output files are sealed with ChaCha20-Poly1305 (see mysqlcsvdecrypt)")
+	encryptKeyfileFlag := flag.String("encrypt-keyfile", "", "path to a file containing the --encrypt-key value")
 
 	flag.Parse()
 	args := flag.Args()
@@ -166,6 +134,11 @@ func main() {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
+	if _, ok := formatExt[*format]; !ok {
+		fmt.Println("-format must be one of csv, ndjson, json-array.")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
 	if len(args) < 1 {
 		fmt.Println("Database name must be defined.")
 		flag.PrintDefaults()
@@ -173,35 +146,51 @@ func main() {
 	}
 	dbName := args[0]
 
+	encryptKey, err := resolveEncryptKey(*encryptKeyFlag, *encryptKeyfileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad --encrypt-key/--encrypt-keyfile: %s\n", err)
+		os.Exit(1)
+	}
+
 	dbUrl := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", *dbUser, *dbPassword, *dbHost, *dbPort, dbName)
+	if *compressProtocol {
+		dbUrl += "?compress=true"
+	}
 	//fmt.Println("DB url:", dbUrl)
 	db, err := sql.Open("mysql", dbUrl)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Could not connect to server: %s\n", err)
+		os.Exit(1)
 	}
 	defer db.Close()
 
-	var q queryable
-	if *useTransaction {
-		tx, err := db.Begin()
-		if err != nil {
-			panic(err)
+	if *compressProtocol {
+		// Not every go-sql-driver/mysql build negotiates CLIENT_COMPRESS, and some
+		// servers reject it outright, so make sure the connection actually works
+		// before committing to it and fall back to an uncompressed connection.
+		if err := db.Ping(); err != nil {
+			fmt.Fprintf(os.Stderr, "Server rejected compressed protocol, falling back to uncompressed: %s\n", err)
+			db.Close()
+			dbUrl = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", *dbUser, *dbPassword, *dbHost, *dbPort, dbName)
+			db, err = sql.Open("mysql", dbUrl)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not connect to server: %s\n", err)
+				os.Exit(1)
+			}
+			defer db.Close()
 		}
-		defer tx.Rollback()
-		q = tx
-	} else {
-		q = db
 	}
 
 	var tables []string
 	if len(args) > 1 {
 		tables = args[1:]
 	} else {
-		tables, err = getTables(q)
+		tables, err = getTables(db)
 	}
 
-	err = dump(tables, q, *outputDir, *compressFiles, *skipHeader)
+	err = dump(db, dbName, tables, *outputDir, *format, *compressFiles, *skipHeader, encryptKey, *parallel, *useTransaction, *chunkSize, *splitFiles, *resume)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
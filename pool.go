@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// multiError collects one error per table so a single failing table doesn't
+// hide failures in the others.
+type multiError struct {
+	errs []error
+}
+
+func (m *multiError) add(table string, err error) {
+	if err != nil {
+		m.errs = append(m.errs, fmt.Errorf("%s: %w", table, err))
+	}
+}
+
+func (m *multiError) errOrNil() error {
+	if len(m.errs) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *multiError) Error() string {
+	msgs := make([]string, len(m.errs))
+	for i, err := range m.errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d table(s) failed:\n%s", len(m.errs), strings.Join(msgs, "\n"))
+}
+
+// connQueryable adapts a single *sql.Conn to the queryable interface so a
+// worker can keep reusing the same connection (and transaction) across
+// multiple dumpTable calls.
+type connQueryable struct {
+	conn *sql.Conn
+}
+
+func (c *connQueryable) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.conn.QueryContext(context.Background(), query, args...)
+}
+
+// estimateRows gives a rough row count for a table, used only to drive the
+// progress bar/ETA -- it's read from information_schema so it's cheap but
+// approximate (MyISAM is exact, InnoDB is an estimate).
+func estimateRows(db queryable, dbName, table string) int64 {
+	rows, err := db.Query(
+		"SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?",
+		dbName, table)
+	if err != nil {
+		return 0
+	}
+	defer rows.Close()
+	var count int64
+	if rows.Next() {
+		rows.Scan(&count)
+	}
+	return count
+}
+
+// progressTracker prints a single consolidated "[done/total tables] rows
+// done/estimate (pct%) eta=..." line as workers report progress.
+type progressTracker struct {
+	mu            sync.Mutex
+	start         time.Time
+	totalTables   int
+	tablesDone    int
+	totalEstimate int64
+	rowsDone      map[string]int64
+}
+
+func newProgressTracker(totalTables int, estimates map[string]int64) *progressTracker {
+	var total int64
+	for _, n := range estimates {
+		total += n
+	}
+	return &progressTracker{
+		start:         time.Now(),
+		totalTables:   totalTables,
+		totalEstimate: total,
+		rowsDone:      make(map[string]int64, totalTables),
+	}
+}
+
+func (p *progressTracker) report(table string, rows int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rowsDone[table] = rows
+	p.print()
+}
+
+func (p *progressTracker) tableDone(table string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tablesDone++
+	p.print()
+}
+
+func (p *progressTracker) print() {
+	var done int64
+	for _, n := range p.rowsDone {
+		done += n
+	}
+	elapsed := time.Since(p.start)
+	var eta time.Duration
+	if done > 0 && p.totalEstimate > done {
+		perRow := elapsed / time.Duration(done)
+		eta = perRow * time.Duration(p.totalEstimate-done)
+	}
+	fmt.Printf("\r[%d/%d tables] %d/%d rows (eta %s)    ", p.tablesDone, p.totalTables, done, p.totalEstimate, eta.Round(time.Second))
+}
+
+// dump fans the given tables out across up to parallel worker connections.
+func dump(db *sql.DB, dbName string, tables []string, outputDir, format string, compressOut, skipHeader bool, encryptKey []byte, parallel int, useTransaction bool, chunkSize int64, splitFiles, resume bool) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	if parallel > len(tables) {
+		parallel = len(tables)
+	}
+	if parallel < 1 {
+		return nil
+	}
+	ctx := context.Background()
+
+	estimates := make(map[string]int64, len(tables))
+	columns := make(map[string][]columnMeta, len(tables))
+	for _, table := range tables {
+		estimates[table] = estimateRows(db, dbName, table)
+		cols, err := columnMetaFor(db, dbName, table)
+		if err != nil {
+			return err
+		}
+		columns[table] = cols
+	}
+	progress := newProgressTracker(len(tables), estimates)
+	defer fmt.Println()
+
+	conns := make([]*sql.Conn, parallel)
+	for i := range conns {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+
+	var coords binlogCoords
+	var err error
+	if useTransaction {
+		coords, err = beginConsistentSnapshots(ctx, db, conns)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			for _, conn := range conns {
+				conn.ExecContext(ctx, "COMMIT")
+			}
+		}()
+	} else if c, err := captureBinlogCoords(ctx, conns[0]); err == nil {
+		coords = c
+	}
+
+	if err := writeDumpMetadata(outputDir, coords, tables, estimates, columns); err != nil {
+		return err
+	}
+
+	tableCh := make(chan string)
+	go func() {
+		defer close(tableCh)
+		for _, table := range tables {
+			tableCh <- table
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := &multiError{}
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn *sql.Conn) {
+			defer wg.Done()
+			q := &connQueryable{conn: conn}
+			for table := range tableCh {
+				reportProgress := func(rows int64) {
+					progress.report(table, rows)
+				}
+				var err error
+				if chunkSize > 0 {
+					err = dumpTableChunked(table, q, dbName, outputDir, format, compressOut, skipHeader, encryptKey, chunkSize, splitFiles, resume, reportProgress)
+				} else {
+					err = dumpTable(table, q, outputDir, format, compressOut, skipHeader, encryptKey, reportProgress)
+				}
+				progress.tableDone(table)
+				mu.Lock()
+				errs.add(table, err)
+				mu.Unlock()
+			}
+		}(conn)
+	}
+	wg.Wait()
+
+	return errs.errOrNil()
+}
+
+// beginConsistentSnapshots takes a brief global read lock so every
+// connection's consistent snapshot starts from the same point, captures the
+// binlog coordinates of that point, then starts the snapshot transaction on
+// each connection and releases the lock. A single connection has nothing to
+// synchronize with, so the lock is skipped in that case; if the server won't
+// grant the lock at all (e.g. no RELOAD privilege) or won't give up binlog
+// coordinates (e.g. no REPLICATION CLIENT), it falls back to proceeding
+// without them rather than failing the whole dump.
+func beginConsistentSnapshots(ctx context.Context, db *sql.DB, conns []*sql.Conn) (binlogCoords, error) {
+	if len(conns) <= 1 {
+		coords, _ := captureBinlogCoords(ctx, conns[0])
+		if _, err := conns[0].ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return coords, err
+		}
+		return coords, nil
+	}
+
+	lockConn, err := db.Conn(ctx)
+	if err != nil {
+		return binlogCoords{}, err
+	}
+	defer lockConn.Close()
+
+	haveLock := true
+	if _, err := lockConn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+		fmt.Printf("Could not take a global read lock (%s), snapshots may not be perfectly consistent with each other\n", err)
+		haveLock = false
+	}
+
+	coords, err := captureBinlogCoords(ctx, lockConn)
+	if err != nil {
+		fmt.Printf("Could not capture binlog coordinates (%s), metadata.json will have none\n", err)
+		coords = binlogCoords{}
+	}
+
+	for _, conn := range conns {
+		if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			if haveLock {
+				lockConn.ExecContext(ctx, "UNLOCK TABLES")
+			}
+			return binlogCoords{}, err
+		}
+	}
+
+	if haveLock {
+		if _, err := lockConn.ExecContext(ctx, "UNLOCK TABLES"); err != nil {
+			return binlogCoords{}, err
+		}
+	}
+	return coords, nil
+}
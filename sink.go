@@ -0,0 +1,85 @@
+package main
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"os"
+)
+
+// dumpSink bundles the file, optional gzip/encryption layers and row encoder
+// behind a single output file, closing them innermost-first.
+type dumpSink struct {
+	w   rowWriter
+	gz  *gzip.Writer
+	enc *encryptWriter
+	f   *os.File
+}
+
+func newDumpSink(fname, format string, compressOut, skipHeader bool, encryptKey, ad []byte) (*dumpSink, error) {
+	f, err := os.Create(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = f
+	s := &dumpSink{f: f}
+	if encryptKey != nil {
+		enc, err := newEncryptWriter(out, encryptKey, ad)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.enc = enc
+		out = enc
+	}
+	if compressOut {
+		s.gz = gzip.NewWriter(out)
+		out = s.gz
+	}
+
+	w, err := newRowWriter(format, out, skipHeader)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.w = w
+	return s, nil
+}
+
+func (s *dumpSink) WriteHeader(columns []string) error {
+	return s.w.WriteHeader(columns)
+}
+
+func (s *dumpSink) WriteRow(columnTypes []*sql.ColumnType, values []interface{}) error {
+	return s.w.WriteRow(columnTypes, values)
+}
+
+func (s *dumpSink) Close() error {
+	if err := s.w.Close(); err != nil {
+		return err
+	}
+	if s.gz != nil {
+		if err := s.gz.Close(); err != nil {
+			return err
+		}
+	}
+	if s.enc != nil {
+		if err := s.enc.Close(); err != nil {
+			return err
+		}
+	}
+	return s.f.Close()
+}
+
+// outputName builds the on-disk name for a table's dump.
+func outputName(outputDir, table, format string, compressOut bool, encryptKey []byte) string {
+	fname := outputDir + "/" + table + formatExt[format]
+	if compressOut {
+		fname += ".gz"
+	}
+	if encryptKey != nil {
+		fname += ".enc"
+	}
+	return fname
+}